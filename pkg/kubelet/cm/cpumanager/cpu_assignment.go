@@ -0,0 +1,117 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpumanager
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpumanager/topology"
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
+)
+
+// cpuAccumulator is used to track the set of CPUs still available when
+// packing a request onto a topology, NUMA node by NUMA node.
+type cpuAccumulator struct {
+	topo          *topology.CPUTopology
+	details       topology.CPUDetails
+	numCPUsNeeded int
+	result        cpuset.CPUSet
+}
+
+func newCPUAccumulator(topo *topology.CPUTopology, availableCPUs cpuset.CPUSet, numCPUs int) *cpuAccumulator {
+	return &cpuAccumulator{
+		topo:          topo,
+		details:       topo.CPUDetails.KeepOnly(availableCPUs),
+		numCPUsNeeded: numCPUs,
+		result:        cpuset.NewCPUSet(),
+	}
+}
+
+func (a *cpuAccumulator) take(cpus cpuset.CPUSet) {
+	a.result = a.result.Union(cpus)
+	a.details = a.details.KeepOnly(a.details.CPUs().Difference(a.result))
+	a.numCPUsNeeded -= cpus.Size()
+}
+
+func (a *cpuAccumulator) isSatisfied() bool {
+	return a.numCPUsNeeded < 1
+}
+
+func (a *cpuAccumulator) isFailed() bool {
+	return a.numCPUsNeeded > a.details.CPUs().Size()
+}
+
+// takeFullNUMANodes takes any NUMA nodes that are fully available
+// (all of their CPUs are unused) until the request is satisfied.
+func (a *cpuAccumulator) takeFullNUMANodes() {
+	for _, numa := range a.details.NUMANodes().ToSlice() {
+		cpusInNUMANode := a.topo.CPUDetails.CPUsInNUMANodes(numa)
+		if !a.needs(cpusInNUMANode.Size()) {
+			continue
+		}
+		if !a.details.CPUsInNUMANodes(numa).Equals(cpusInNUMANode) {
+			continue
+		}
+		a.take(cpusInNUMANode)
+	}
+}
+
+func (a *cpuAccumulator) needs(n int) bool {
+	return a.numCPUsNeeded >= n
+}
+
+// takeByTopologyNUMAPacked chooses `numCPUs` CPUs from `availableCPUs`. It
+// first takes whole, fully free NUMA nodes that fit in the request, then
+// takes the rest one CPU at a time starting from the NUMA node with the
+// fewest free CPUs. The result does not always span the fewest NUMA nodes
+// possible.
+func takeByTopologyNUMAPacked(topo *topology.CPUTopology, availableCPUs cpuset.CPUSet, numCPUs int) (cpuset.CPUSet, error) {
+	acc := newCPUAccumulator(topo, availableCPUs, numCPUs)
+	if acc.isSatisfied() {
+		return acc.result, nil
+	}
+	if acc.isFailed() {
+		return cpuset.NewCPUSet(), fmt.Errorf("not enough cpus available to satisfy request")
+	}
+
+	// Start by taking any full NUMA nodes that fit the remaining request.
+	acc.takeFullNUMANodes()
+	if acc.isSatisfied() {
+		return acc.result, nil
+	}
+
+	// Then take whatever is left, one CPU at a time, from the NUMA node
+	// with the fewest remaining free CPUs (to keep sockets packed).
+	remaining := acc.details.NUMANodes().ToSlice()
+	sort.Slice(remaining, func(i, j int) bool {
+		return acc.details.CPUsInNUMANodes(remaining[i]).Size() < acc.details.CPUsInNUMANodes(remaining[j]).Size()
+	})
+	for _, numa := range remaining {
+		for _, cpu := range acc.details.CPUsInNUMANodes(numa).ToSlice() {
+			if acc.isSatisfied() {
+				break
+			}
+			acc.take(cpuset.NewCPUSet(cpu))
+		}
+	}
+
+	if !acc.isSatisfied() {
+		return cpuset.NewCPUSet(), fmt.Errorf("failed to allocate cpus")
+	}
+	return acc.result, nil
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpumanager
+
+import (
+	"testing"
+
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpumanager/topology"
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
+)
+
+// twoNUMAUnevenTopology returns a 5 CPU topology with two NUMA nodes of
+// unequal size (CPUs 0-2 on NUMA0, CPUs 3-4 on NUMA1), used to exercise the
+// leftover, one-CPU-at-a-time pass of takeByTopologyNUMAPacked once no NUMA
+// node can be taken whole.
+func twoNUMAUnevenTopology() *topology.CPUTopology {
+	return &topology.CPUTopology{
+		NumCPUs:    5,
+		NumCores:   5,
+		NumSockets: 2,
+		CPUDetails: topology.CPUDetails{
+			0: topology.CPUInfo{CoreID: 0, SocketID: 0, NUMANodeID: 0},
+			1: topology.CPUInfo{CoreID: 1, SocketID: 0, NUMANodeID: 0},
+			2: topology.CPUInfo{CoreID: 2, SocketID: 0, NUMANodeID: 0},
+			3: topology.CPUInfo{CoreID: 3, SocketID: 1, NUMANodeID: 1},
+			4: topology.CPUInfo{CoreID: 4, SocketID: 1, NUMANodeID: 1},
+		},
+	}
+}
+
+func TestTakeByTopologyNUMAPackedTakesFullNode(t *testing.T) {
+	topo := twoSocketTopology() // NUMA0: 0,1; NUMA1: 2,3
+	result, err := takeByTopologyNUMAPacked(topo, cpuset.NewCPUSet(0, 1, 2, 3), 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Equals(cpuset.NewCPUSet(0, 1)) {
+		t.Errorf("expected the full NUMA0 node (0,1) taken first, got %v", result)
+	}
+}
+
+func TestTakeByTopologyNUMAPackedSpansNodesWhenNeeded(t *testing.T) {
+	topo := twoSocketTopology() // NUMA0: 0,1; NUMA1: 2,3
+	result, err := takeByTopologyNUMAPacked(topo, cpuset.NewCPUSet(0, 1, 2, 3), 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Equals(cpuset.NewCPUSet(0, 1, 2)) {
+		t.Errorf("expected full NUMA0 (0,1) plus one CPU from NUMA1, got %v", result)
+	}
+}
+
+// TestTakeByTopologyNUMAPackedPrefersFewestRemainingCPUs verifies that once
+// no NUMA node can be taken whole, the leftover pass draws from the NUMA
+// node with the fewest remaining free CPUs first, rather than in ascending
+// NUMA-ID order, so a request that must spill past a full node still keeps
+// sockets as packed as possible.
+func TestTakeByTopologyNUMAPackedPrefersFewestRemainingCPUs(t *testing.T) {
+	topo := twoNUMAUnevenTopology() // NUMA0: 0,1,2; NUMA1: 3,4
+	available := cpuset.NewCPUSet(0, 1, 3)
+
+	result, err := takeByTopologyNUMAPacked(topo, available, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Equals(cpuset.NewCPUSet(3)) {
+		t.Errorf("expected CPU 3 from the NUMA node with fewer remaining free CPUs, got %v", result)
+	}
+}
+
+func TestTakeByTopologyNUMAPackedInsufficientCPUs(t *testing.T) {
+	topo := twoSocketTopology()
+	_, err := takeByTopologyNUMAPacked(topo, cpuset.NewCPUSet(0), 2)
+	if err == nil {
+		t.Fatalf("expected an error when not enough CPUs are available")
+	}
+}
+
+// TestAddContainerAllocatesExclusiveCPUsAcrossNUMANodes exercises
+// staticPolicy.AddContainer end to end for an ordinary Guaranteed
+// integer-CPU pod: it should allocate via allocateCPUs/
+// takeByTopologyNUMAPacked, record the result in state, and remove the
+// allocated CPUs from the default (shared) set.
+func TestAddContainerAllocatesExclusiveCPUsAcrossNUMANodes(t *testing.T) {
+	topo := twoSocketTopology() // NUMA0: 0,1; NUMA1: 2,3
+	p := &staticPolicy{
+		topology:     topo,
+		reserved:     cpuset.NewCPUSet(),
+		isolatedCPUs: cpuset.NewCPUSet(),
+	}
+	s := &recordingState{defaultCPUs: cpuset.NewCPUSet(0, 1, 2, 3)}
+
+	pod := isolGuaranteedPod("3", 0)
+	pod.UID = "guaranteed-pod-uid"
+	container := pod.Spec.Containers[0]
+
+	if err := p.AddContainer(s, pod, &container); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cset, ok := s.GetCPUSet(string(pod.UID), container.Name)
+	if !ok {
+		t.Fatalf("expected a cpuset to be recorded for the container")
+	}
+	if cset.Size() != 3 {
+		t.Fatalf("expected 3 exclusively allocated CPUs, got %v", cset)
+	}
+	if s.GetDefaultCPUSet().Intersection(cset).Size() != 0 {
+		t.Errorf("expected allocated CPUs %v removed from the default set, got default %v", cset, s.GetDefaultCPUSet())
+	}
+}
@@ -24,14 +24,19 @@ import (
 
 	cadvisorapi "github.com/google/cadvisor/info/v1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog"
 
+	"k8s.io/client-go/dynamic"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
 	"k8s.io/kubernetes/pkg/kubelet/cm/cpumanager/containermap"
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpumanager/noderesourcetopology"
 	"k8s.io/kubernetes/pkg/kubelet/cm/cpumanager/state"
 	"k8s.io/kubernetes/pkg/kubelet/cm/cpumanager/topology"
 	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
+	"k8s.io/kubernetes/pkg/kubelet/cm/devicemanager"
 	"k8s.io/kubernetes/pkg/kubelet/cm/topologymanager"
 	"k8s.io/kubernetes/pkg/kubelet/config"
 	kubecontainer "k8s.io/kubernetes/pkg/kubelet/container"
@@ -47,6 +52,22 @@ type runtimeService interface {
 
 type policyName string
 
+// NodeResourceTopologyOptions configures the optional NodeResourceTopology
+// publisher. Grouped into a struct, rather than further lengthening
+// NewManager's parameter list, since most of these only matter together.
+type NodeResourceTopologyOptions struct {
+	// Enabled turns on periodic publication of a NodeResourceTopology CR.
+	Enabled bool
+	// DynamicClient is used to create/update the CR; required if Enabled.
+	DynamicClient dynamic.Interface
+	// GroupVersionResource is pluggable so operators can target an
+	// existing NodeResourceTopology-compatible CRD schema. Defaults to
+	// noderesourcetopology.DefaultGroupVersionResource if zero.
+	GroupVersionResource schema.GroupVersionResource
+	// NodeName is the node (and CR) name to publish under.
+	NodeName string
+}
+
 // cpuManagerStateFileName is the file name where cpu manager stores its state
 const cpuManagerStateFileName = "cpu_manager_state"
 
@@ -72,6 +93,17 @@ type Manager interface {
 	// and is consulted to achieve NUMA aware resource alignment among this
 	// and other resource controllers.
 	GetTopologyHints(v1.Pod, v1.Container) map[string][]topologymanager.TopologyHint
+
+	// GetAllocatableCPUs returns the set of CPUs the active policy may hand
+	// out to pods, excluding reserved and isolated CPUs. Consumed by the
+	// NodeResourceTopology publisher to report per-NUMA-zone capacity.
+	GetAllocatableCPUs() cpuset.CPUSet
+
+	// GetAllocatedCPUsPerNUMA returns the CPUs currently assigned to
+	// containers, bucketed by NUMA node ID. Only CPUs in the allocatable
+	// pool (see GetAllocatableCPUs) are counted. Consumed by the
+	// NodeResourceTopology publisher to report per-NUMA-zone availability.
+	GetAllocatedCPUsPerNUMA() map[int]cpuset.CPUSet
 }
 
 type manager struct {
@@ -111,6 +143,18 @@ type manager struct {
 
 	// stateFileDirectory holds the directory where the state file for checkpoints is held.
 	stateFileDirectory string
+
+	// disableCFSQuotaForExclusiveCPUs, when true, clears the CFS CPU quota
+	// (CpuQuota = -1) on containers that are assigned exclusive CPUs, so
+	// the first process starts unthrottled instead of being limited by a
+	// quota that is now redundant with pinned, dedicated CPUs.
+	disableCFSQuotaForExclusiveCPUs bool
+
+	// topologyPublisher, if non-nil, periodically publishes a
+	// NodeResourceTopology CR describing this node's CPU topology and
+	// current per-NUMA-zone allocation, for topology-aware external
+	// schedulers. Runs on its own ticker, started alongside reconcileState.
+	topologyPublisher *noderesourcetopology.Publisher
 }
 
 var _ Manager = &manager{}
@@ -121,7 +165,7 @@ func (s *sourcesReadyStub) AddSource(source string) {}
 func (s *sourcesReadyStub) AllReady() bool          { return true }
 
 // NewManager creates new cpu manager based on provided policy
-func NewManager(cpuPolicyName string, reconcilePeriod time.Duration, machineInfo *cadvisorapi.MachineInfo, numaNodeInfo topology.NUMANodeInfo, specificCPUs cpuset.CPUSet, nodeAllocatableReservation v1.ResourceList, stateFileDirectory string, affinity topologymanager.Store) (Manager, error) {
+func NewManager(cpuPolicyName string, cpuPolicyOptions map[string]string, reconcilePeriod time.Duration, machineInfo *cadvisorapi.MachineInfo, numaNodeInfo topology.NUMANodeInfo, specificCPUs cpuset.CPUSet, nodeAllocatableReservation v1.ResourceList, stateFileDirectory string, affinity topologymanager.Store, devicesManager devicemanager.Manager, isolatedCPUs cpuset.CPUSet, namespaceLister corelisters.NamespaceLister, platformLabelKey string, platformLabelValue string, disableCFSQuotaForExclusiveCPUs bool, nrtOptions NodeResourceTopologyOptions) (Manager, error) {
 	var topo *topology.CPUTopology
 	var policy Policy
 
@@ -155,7 +199,7 @@ func NewManager(cpuPolicyName string, reconcilePeriod time.Duration, machineInfo
 		// exclusively allocated.
 		reservedCPUsFloat := float64(reservedCPUs.MilliValue()) / 1000
 		numReservedCPUs := int(math.Ceil(reservedCPUsFloat))
-		policy, err = NewStaticPolicy(topo, numReservedCPUs, specificCPUs, affinity)
+		policy, err = NewStaticPolicy(topo, numReservedCPUs, specificCPUs, affinity, devicesManager, isolatedCPUs, namespaceLister, platformLabelKey, platformLabelValue, cpuPolicyOptions)
 		if err != nil {
 			return nil, fmt.Errorf("new static policy error: %v", err)
 		}
@@ -165,14 +209,28 @@ func NewManager(cpuPolicyName string, reconcilePeriod time.Duration, machineInfo
 	}
 
 	manager := &manager{
-		policy:                     policy,
-		reconcilePeriod:            reconcilePeriod,
-		containerMap:               containermap.NewContainerMap(),
-		topology:                   topo,
-		nodeAllocatableReservation: nodeAllocatableReservation,
-		stateFileDirectory:         stateFileDirectory,
+		policy:                          policy,
+		reconcilePeriod:                 reconcilePeriod,
+		containerMap:                    containermap.NewContainerMap(),
+		topology:                        topo,
+		nodeAllocatableReservation:      nodeAllocatableReservation,
+		stateFileDirectory:              stateFileDirectory,
+		disableCFSQuotaForExclusiveCPUs: disableCFSQuotaForExclusiveCPUs,
 	}
 	manager.sourcesReady = &sourcesReadyStub{}
+
+	if nrtOptions.Enabled && topo != nil {
+		manager.topologyPublisher = noderesourcetopology.NewPublisher(
+			nrtOptions.DynamicClient,
+			noderesourcetopology.Config{
+				NodeName:             nrtOptions.NodeName,
+				GroupVersionResource: nrtOptions.GroupVersionResource,
+			},
+			topo,
+			manager,
+		)
+	}
+
 	return manager, nil
 }
 
@@ -201,6 +259,10 @@ func (m *manager) Start(activePods ActivePodsFunc, sourcesReady config.SourcesRe
 		return nil
 	}
 	go wait.Until(func() { m.reconcileState() }, m.reconcilePeriod, wait.NeverStop)
+
+	if m.topologyPublisher != nil {
+		go m.topologyPublisher.Run(wait.NeverStop, m.reconcilePeriod)
+	}
 	return nil
 }
 
@@ -227,7 +289,7 @@ func (m *manager) AddContainer(p *v1.Pod, c *v1.Container, containerID string) e
 	m.Unlock()
 
 	if !cpus.IsEmpty() {
-		err = m.updateContainerCPUSet(containerID, cpus)
+		err = m.updateContainerResources(containerID, cpus, m.shouldDisableCFSQuota(p, c))
 		if err != nil {
 			klog.Errorf("[cpumanager] AddContainer error: %v", err)
 			m.Lock()
@@ -298,6 +360,41 @@ func (m *manager) GetTopologyHints(pod v1.Pod, container v1.Container) map[strin
 	return m.policy.GetTopologyHints(m.state, pod, container)
 }
 
+func (m *manager) GetAllocatableCPUs() cpuset.CPUSet {
+	m.Lock()
+	defer m.Unlock()
+	return m.policy.GetCPUPools().Allocatable
+}
+
+func (m *manager) GetAllocatedCPUsPerNUMA() map[int]cpuset.CPUSet {
+	m.Lock()
+	defer m.Unlock()
+
+	result := make(map[int]cpuset.CPUSet)
+	if m.topology == nil || m.state == nil {
+		return result
+	}
+	// Only CPUs drawn from the allocatable pool count as "allocated" here:
+	// platform pods (pinned to the reserved pool) and isolated-CPU
+	// containers are never part of GetCPUPools().Allocatable, so including
+	// them would make buildZones() subtract CPUs that were never counted
+	// in a zone's allocatable total in the first place.
+	allocatable := m.policy.GetCPUPools().Allocatable
+	for _, containers := range m.state.GetCPUAssignments() {
+		for _, cset := range containers {
+			for _, cpu := range cset.Intersection(allocatable).ToSlice() {
+				info, ok := m.topology.CPUDetails[cpu]
+				if !ok {
+					continue
+				}
+				numa := info.NUMANodeID
+				result[numa] = result[numa].Union(cpuset.NewCPUSet(cpu))
+			}
+		}
+	}
+	return result
+}
+
 type reconciledContainer struct {
 	podName       string
 	containerName string
@@ -405,7 +502,7 @@ func (m *manager) reconcileState() (success []reconciledContainer, failure []rec
 			}
 
 			klog.V(4).Infof("[cpumanager] reconcileState: updating container (pod: %s, container: %s, container id: %s, cpuset: \"%v\")", pod.Name, container.Name, containerID, cset)
-			err = m.updateContainerCPUSet(containerID, cset)
+			err = m.updateContainerResources(containerID, cset, m.shouldDisableCFSQuota(pod, &container))
 			if err != nil {
 				klog.Errorf("[cpumanager] reconcileState: failed to update container (pod: %s, container: %s, container id: %s, cpuset: \"%v\", error: %v)", pod.Name, container.Name, containerID, cset, err)
 				failure = append(failure, reconciledContainer{pod.Name, container.Name, containerID})
@@ -433,14 +530,36 @@ func findContainerIDByName(status *v1.PodStatus, name string) (string, error) {
 	return "", fmt.Errorf("unable to find ID for container with name %v in pod status (it may not be running)", name)
 }
 
-func (m *manager) updateContainerCPUSet(containerID string, cpus cpuset.CPUSet) error {
+// shouldDisableCFSQuota reports whether container's CFS CPU quota should be
+// cleared: only when disableCFSQuotaForExclusiveCPUs is enabled and the
+// container actually owns exclusive CPUs. A Guaranteed integer-CPU
+// container that is platform infra is pinned to the shared reserved pool
+// instead (see staticPolicy.IsKubeInfra), so it must keep its quota to stay
+// throttled like the other platform/system workloads it shares that pool
+// with.
+func (m *manager) shouldDisableCFSQuota(pod *v1.Pod, container *v1.Container) bool {
+	return m.disableCFSQuotaForExclusiveCPUs && guaranteedCPUs(pod, container) != 0 && !m.policy.IsKubeInfra(pod)
+}
+
+// updateContainerResources pushes the container's assigned cpuset through to
+// the container runtime and, when disableCFSQuota is true, clears the CFS
+// CPU quota in the same call so a container that owns exclusive CPUs starts
+// (or is reconciled) unthrottled. Carrying both in a single
+// UpdateContainerResources call keeps the change atomic from the runtime's
+// point of view.
+func (m *manager) updateContainerResources(containerID string, cpus cpuset.CPUSet, disableCFSQuota bool) error {
 	// TODO: Consider adding a `ResourceConfigForContainer` helper in
 	// helpers_linux.go similar to what exists for pods.
 	// It would be better to pass the full container resources here instead of
 	// this patch-like partial resources.
-	return m.containerRuntime.UpdateContainerResources(
-		containerID,
-		&runtimeapi.LinuxContainerResources{
-			CpusetCpus: cpus.String(),
-		})
+	resources := &runtimeapi.LinuxContainerResources{
+		CpusetCpus: cpus.String(),
+	}
+	if disableCFSQuota {
+		// A negative quota tells the runtime to disable CFS throttling for
+		// this container; CpuPeriod is intentionally left unset so the
+		// existing period configured for the container is preserved.
+		resources.CpuQuota = -1
+	}
+	return m.containerRuntime.UpdateContainerResources(containerID, resources)
 }
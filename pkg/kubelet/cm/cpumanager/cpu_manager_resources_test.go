@@ -0,0 +1,129 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpumanager
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
+)
+
+func quotaTestGuaranteedPod(cpuRequest string) *v1.Pod {
+	resources := v1.ResourceRequirements{
+		Requests: v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse(cpuRequest),
+			v1.ResourceMemory: resource.MustParse("1Gi"),
+		},
+	}
+	resources.Limits = resources.Requests.DeepCopy()
+	return &v1.Pod{
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{Name: "test", Resources: resources}},
+		},
+	}
+}
+
+type fakeRuntimeService struct {
+	lastResources *runtimeapi.LinuxContainerResources
+}
+
+func (f *fakeRuntimeService) UpdateContainerResources(id string, resources *runtimeapi.LinuxContainerResources) error {
+	f.lastResources = resources
+	return nil
+}
+
+func TestUpdateContainerResourcesDisablesQuotaForExclusiveCPUs(t *testing.T) {
+	runtime := &fakeRuntimeService{}
+	m := &manager{containerRuntime: runtime}
+
+	if err := m.updateContainerResources("container-id", cpuset.NewCPUSet(2, 3), true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if runtime.lastResources.CpuQuota != -1 {
+		t.Errorf("expected CpuQuota -1 for exclusive-CPU container, got %d", runtime.lastResources.CpuQuota)
+	}
+	if runtime.lastResources.CpusetCpus != "2-3" {
+		t.Errorf("expected CpusetCpus \"2-3\", got %q", runtime.lastResources.CpusetCpus)
+	}
+}
+
+func TestUpdateContainerResourcesLeavesQuotaIntactOtherwise(t *testing.T) {
+	runtime := &fakeRuntimeService{}
+	m := &manager{containerRuntime: runtime}
+
+	if err := m.updateContainerResources("container-id", cpuset.NewCPUSet(2, 3), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if runtime.lastResources.CpuQuota != 0 {
+		t.Errorf("expected CpuQuota left unset (0), got %d", runtime.lastResources.CpuQuota)
+	}
+}
+
+func TestShouldDisableCFSQuota(t *testing.T) {
+	guaranteed := quotaTestGuaranteedPod("2")
+	fractional := quotaTestGuaranteedPod("500m")
+
+	testCases := []struct {
+		description   string
+		featureOn     bool
+		pod           *v1.Pod
+		isKubeInfra   bool
+		expectDisable bool
+	}{
+		{
+			description:   "exclusive-CPU container with feature on",
+			featureOn:     true,
+			pod:           guaranteed,
+			expectDisable: true,
+		},
+		{
+			description:   "feature off",
+			featureOn:     false,
+			pod:           guaranteed,
+			expectDisable: false,
+		},
+		{
+			description:   "fractional CPU request never qualifies",
+			featureOn:     true,
+			pod:           fractional,
+			expectDisable: false,
+		},
+		{
+			description:   "platform pod keeps its quota despite integer CPU request",
+			featureOn:     true,
+			pod:           guaranteed,
+			isKubeInfra:   true,
+			expectDisable: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			m := &manager{
+				disableCFSQuotaForExclusiveCPUs: tc.featureOn,
+				policy:                          &fakePoolsPolicy{isKubeInfra: tc.isKubeInfra},
+			}
+			if got := m.shouldDisableCFSQuota(tc.pod, &tc.pod.Spec.Containers[0]); got != tc.expectDisable {
+				t.Errorf("shouldDisableCFSQuota() = %v, expected %v", got, tc.expectDisable)
+			}
+		})
+	}
+}
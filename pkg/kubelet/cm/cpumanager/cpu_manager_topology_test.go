@@ -0,0 +1,149 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpumanager
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpumanager/state"
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpumanager/topology"
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
+	"k8s.io/kubernetes/pkg/kubelet/cm/topologymanager"
+)
+
+// fakePoolsPolicy is a minimal Policy whose only behavior that matters for
+// these tests is the CPU pools it reports and whether it classifies a pod
+// as platform infra, standing in for a staticPolicy configured with some
+// combination of reserved/isolated CPUs.
+type fakePoolsPolicy struct {
+	pools       CPUPools
+	isKubeInfra bool
+}
+
+func (f *fakePoolsPolicy) Name() string              { return "fake" }
+func (f *fakePoolsPolicy) Start(s state.State) error { return nil }
+func (f *fakePoolsPolicy) AddContainer(s state.State, pod *v1.Pod, container *v1.Container) error {
+	return nil
+}
+func (f *fakePoolsPolicy) RemoveContainer(s state.State, podUID string, containerName string) error {
+	return nil
+}
+func (f *fakePoolsPolicy) GetTopologyHints(s state.State, pod v1.Pod, container v1.Container) map[string][]topologymanager.TopologyHint {
+	return nil
+}
+func (f *fakePoolsPolicy) GetCPUPools() CPUPools        { return f.pools }
+func (f *fakePoolsPolicy) IsKubeInfra(pod *v1.Pod) bool { return f.isKubeInfra }
+
+// mockTopologyState is a minimal state.State backed by an in-memory
+// assignments map, sufficient to exercise GetAllocatedCPUsPerNUMA.
+type mockTopologyState struct {
+	assignments state.ContainerCPUAssignments
+	defaultCPUs cpuset.CPUSet
+}
+
+func (m *mockTopologyState) GetCPUSet(podUID, containerName string) (cpuset.CPUSet, bool) {
+	cset, ok := m.assignments[podUID][containerName]
+	return cset, ok
+}
+func (m *mockTopologyState) GetDefaultCPUSet() cpuset.CPUSet { return m.defaultCPUs }
+func (m *mockTopologyState) GetCPUSetOrDefault(podUID, containerName string) cpuset.CPUSet {
+	if cset, ok := m.GetCPUSet(podUID, containerName); ok {
+		return cset
+	}
+	return m.defaultCPUs
+}
+func (m *mockTopologyState) GetCPUAssignments() state.ContainerCPUAssignments           { return m.assignments }
+func (m *mockTopologyState) SetCPUSet(podUID, containerName string, cset cpuset.CPUSet) {}
+func (m *mockTopologyState) SetDefaultCPUSet(cset cpuset.CPUSet)                        {}
+func (m *mockTopologyState) SetCPUAssignments(a state.ContainerCPUAssignments)          {}
+func (m *mockTopologyState) Delete(podUID, containerName string)                        {}
+func (m *mockTopologyState) ClearState()                                                {}
+
+func TestManagerGetAllocatedCPUsPerNUMA(t *testing.T) {
+	topo := &topology.CPUTopology{
+		NumCPUs:    4,
+		NumCores:   4,
+		NumSockets: 2,
+		CPUDetails: topology.CPUDetails{
+			0: topology.CPUInfo{CoreID: 0, SocketID: 0, NUMANodeID: 0},
+			1: topology.CPUInfo{CoreID: 1, SocketID: 0, NUMANodeID: 0},
+			2: topology.CPUInfo{CoreID: 2, SocketID: 1, NUMANodeID: 1},
+			3: topology.CPUInfo{CoreID: 3, SocketID: 1, NUMANodeID: 1},
+		},
+	}
+	st := &mockTopologyState{
+		assignments: state.ContainerCPUAssignments{
+			"pod-a": {
+				"container-a": cpuset.NewCPUSet(1),
+				"container-b": cpuset.NewCPUSet(2, 3),
+			},
+		},
+	}
+	policy := &fakePoolsPolicy{pools: CPUPools{Allocatable: cpuset.NewCPUSet(1, 2, 3)}}
+	m := &manager{topology: topo, state: st, policy: policy}
+
+	perNUMA := m.GetAllocatedCPUsPerNUMA()
+	if perNUMA[0].String() != "1" {
+		t.Errorf("expected NUMA 0 allocated CPUs \"1\", got %v", perNUMA[0])
+	}
+	if perNUMA[1].String() != "2-3" {
+		t.Errorf("expected NUMA 1 allocated CPUs \"2-3\", got %v", perNUMA[1])
+	}
+}
+
+// TestManagerGetAllocatedCPUsPerNUMAExcludesNonAllocatable verifies that
+// CPUs assigned to platform pods (pinned to the reserved pool) or to
+// isolated-CPU containers are not counted as "allocated", since neither
+// pool is part of GetCPUPools().Allocatable and buildZones() would
+// otherwise subtract them from a zone's allocatable total that never
+// included them to begin with.
+func TestManagerGetAllocatedCPUsPerNUMAExcludesNonAllocatable(t *testing.T) {
+	topo := &topology.CPUTopology{
+		NumCPUs:    4,
+		NumCores:   4,
+		NumSockets: 1,
+		CPUDetails: topology.CPUDetails{
+			0: topology.CPUInfo{CoreID: 0, SocketID: 0, NUMANodeID: 0},
+			1: topology.CPUInfo{CoreID: 1, SocketID: 0, NUMANodeID: 0},
+			2: topology.CPUInfo{CoreID: 2, SocketID: 0, NUMANodeID: 0},
+			3: topology.CPUInfo{CoreID: 3, SocketID: 0, NUMANodeID: 0},
+		},
+	}
+	st := &mockTopologyState{
+		assignments: state.ContainerCPUAssignments{
+			"platform-pod": {
+				// Pinned to the reserved CPU, not the allocatable pool.
+				"platform-container": cpuset.NewCPUSet(0),
+			},
+			"isolcpu-pod": {
+				// Pinned to an isolated CPU, not the allocatable pool.
+				"isolcpu-container": cpuset.NewCPUSet(3),
+			},
+			"guaranteed-pod": {
+				"guaranteed-container": cpuset.NewCPUSet(1),
+			},
+		},
+	}
+	policy := &fakePoolsPolicy{pools: CPUPools{Allocatable: cpuset.NewCPUSet(1, 2)}}
+	m := &manager{topology: topo, state: st, policy: policy}
+
+	perNUMA := m.GetAllocatedCPUsPerNUMA()
+	if perNUMA[0].String() != "1" {
+		t.Errorf("expected NUMA 0 allocated CPUs \"1\" (excluding reserved CPU 0 and isolated CPU 3), got %v", perNUMA[0])
+	}
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package noderesourcetopology periodically publishes a cluster-visible
+// NodeResourceTopology-style custom resource describing this node's CPU
+// topology and current per-NUMA-zone CPU availability, so that
+// topology-aware external schedulers can place Guaranteed pods without
+// racing the kubelet's own reconcile loop.
+package noderesourcetopology
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog"
+
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpumanager/topology"
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
+)
+
+// CPUManager is the narrow slice of cpumanager.Manager the publisher needs.
+type CPUManager interface {
+	GetAllocatableCPUs() cpuset.CPUSet
+	GetAllocatedCPUsPerNUMA() map[int]cpuset.CPUSet
+}
+
+// DefaultGroupVersionResource is used when no override is configured. The
+// group/version/resource is intentionally pluggable (see Config) so
+// operators can point the publisher at a schema already installed in their
+// cluster.
+var DefaultGroupVersionResource = schema.GroupVersionResource{
+	Group:    "topology.node.k8s.io",
+	Version:  "v1alpha1",
+	Resource: "noderesourcetopologies",
+}
+
+// Config configures the publisher.
+type Config struct {
+	// NodeName is both the name of the node and the name of the CR
+	// published for it.
+	NodeName string
+	// GroupVersionResource is pluggable so operators can target an
+	// existing NodeResourceTopology-compatible CRD schema.
+	GroupVersionResource schema.GroupVersionResource
+}
+
+// Publisher periodically reconciles a NodeResourceTopology CR describing
+// this node's CPU topology and current allocation state.
+type Publisher struct {
+	client     dynamic.Interface
+	config     Config
+	topo       *topology.CPUTopology
+	cpuManager CPUManager
+}
+
+// NewPublisher returns a Publisher for the given node, topology and CPU
+// manager. client is a generic dynamic client so the publisher does not
+// depend on a generated typed client for the (pluggable) CRD schema.
+func NewPublisher(client dynamic.Interface, config Config, topo *topology.CPUTopology, cpuManager CPUManager) *Publisher {
+	if config.GroupVersionResource == (schema.GroupVersionResource{}) {
+		config.GroupVersionResource = DefaultGroupVersionResource
+	}
+	return &Publisher{
+		client:     client,
+		config:     config,
+		topo:       topo,
+		cpuManager: cpuManager,
+	}
+}
+
+// Run publishes the current topology snapshot every period until stopCh is
+// closed.
+func (p *Publisher) Run(stopCh <-chan struct{}, period time.Duration) {
+	klog.Infof("[noderesourcetopology] publishing %s every %v for node %q", p.config.GroupVersionResource, period, p.config.NodeName)
+	wait.Until(func() {
+		if err := p.PublishOnce(); err != nil {
+			klog.Errorf("[noderesourcetopology] failed to publish NodeResourceTopology for node %q: %v", p.config.NodeName, err)
+		}
+	}, period, stopCh)
+}
+
+// PublishOnce snapshots the current topology and allocation state and
+// creates or updates the corresponding CR. Exported so callers (and tests)
+// can trigger a single publish without waiting for the ticker.
+func (p *Publisher) PublishOnce() error {
+	obj := p.buildNodeResourceTopology()
+	return p.reconcile(obj)
+}
+
+// zone mirrors the capacity/allocatable/available accounting for a single
+// NUMA node.
+type zone struct {
+	name        string
+	capacity    int
+	allocatable int
+	available   int
+}
+
+// buildZones snapshots m.topology plus the CPU manager's allocatable and
+// per-NUMA allocated sets into per-NUMA-zone capacity/allocatable/available
+// counts.
+func (p *Publisher) buildZones() []zone {
+	allocatable := p.cpuManager.GetAllocatableCPUs()
+	allocatedPerNUMA := p.cpuManager.GetAllocatedCPUsPerNUMA()
+
+	var zones []zone
+	for _, numaID := range p.topo.CPUDetails.NUMANodes().ToSlice() {
+		cpusInZone := p.topo.CPUDetails.CPUsInNUMANodes(numaID)
+		allocatableInZone := allocatable.Intersection(cpusInZone)
+		allocatedInZone := allocatedPerNUMA[numaID].Size()
+
+		zones = append(zones, zone{
+			name:        fmt.Sprintf("node-%d", numaID),
+			capacity:    cpusInZone.Size(),
+			allocatable: allocatableInZone.Size(),
+			available:   allocatableInZone.Size() - allocatedInZone,
+		})
+	}
+	return zones
+}
+
+func (p *Publisher) buildNodeResourceTopology() *unstructured.Unstructured {
+	var zoneList []interface{}
+	for _, z := range p.buildZones() {
+		zoneList = append(zoneList, map[string]interface{}{
+			"name": z.name,
+			"type": "Node",
+			"resources": map[string]interface{}{
+				"cpu": map[string]interface{}{
+					"capacity":    int64(z.capacity),
+					"allocatable": int64(z.allocatable),
+					"available":   int64(z.available),
+				},
+			},
+		})
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(p.config.GroupVersionResource.GroupVersion().String())
+	obj.SetKind("NodeResourceTopology")
+	obj.SetName(p.config.NodeName)
+	obj.Object["zones"] = zoneList
+	obj.Object["topologyPolicies"] = []interface{}{"static"}
+	return obj
+}
+
+func (p *Publisher) reconcile(obj *unstructured.Unstructured) error {
+	client := p.client.Resource(p.config.GroupVersionResource)
+
+	existing, err := client.Get(context.TODO(), p.config.NodeName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(context.TODO(), obj, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	_, err = client.Update(context.TODO(), obj, metav1.UpdateOptions{})
+	return err
+}
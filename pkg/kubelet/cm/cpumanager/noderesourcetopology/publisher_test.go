@@ -0,0 +1,148 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package noderesourcetopology
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpumanager/topology"
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
+)
+
+// fixtureTopology is a 4 CPU, 2 NUMA node topology: CPUs 0-1 on NUMA 0 (CPU
+// 0 reserved), CPUs 2-3 on NUMA 1.
+func fixtureTopology() *topology.CPUTopology {
+	return &topology.CPUTopology{
+		NumCPUs:    4,
+		NumCores:   4,
+		NumSockets: 2,
+		CPUDetails: topology.CPUDetails{
+			0: topology.CPUInfo{CoreID: 0, SocketID: 0, NUMANodeID: 0},
+			1: topology.CPUInfo{CoreID: 1, SocketID: 0, NUMANodeID: 0},
+			2: topology.CPUInfo{CoreID: 2, SocketID: 1, NUMANodeID: 1},
+			3: topology.CPUInfo{CoreID: 3, SocketID: 1, NUMANodeID: 1},
+		},
+	}
+}
+
+type fakeCPUManager struct {
+	allocatable cpuset.CPUSet
+	allocated   map[int]cpuset.CPUSet
+}
+
+func (f *fakeCPUManager) GetAllocatableCPUs() cpuset.CPUSet {
+	return f.allocatable
+}
+
+func (f *fakeCPUManager) GetAllocatedCPUsPerNUMA() map[int]cpuset.CPUSet {
+	return f.allocated
+}
+
+func newFakeDynamicClient() *dynamicfake.FakeDynamicClient {
+	scheme := runtime.NewScheme()
+	gvrToListKind := map[schema.GroupVersionResource]string{
+		DefaultGroupVersionResource: "NodeResourceTopologyList",
+	}
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(scheme, gvrToListKind)
+}
+
+func TestPublishOnceCreatesCR(t *testing.T) {
+	topo := fixtureTopology()
+	// CPU 0 is reserved: allocatable excludes it.
+	cm := &fakeCPUManager{
+		allocatable: cpuset.NewCPUSet(1, 2, 3),
+		allocated:   map[int]cpuset.CPUSet{0: cpuset.NewCPUSet(1)},
+	}
+	client := newFakeDynamicClient()
+	pub := NewPublisher(client, Config{NodeName: "node-1"}, topo, cm)
+
+	if err := pub.PublishOnce(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	obj, err := client.Resource(DefaultGroupVersionResource).Get(context.TODO(), "node-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected NodeResourceTopology to be created: %v", err)
+	}
+
+	zones, ok := obj.Object["zones"].([]interface{})
+	if !ok || len(zones) != 2 {
+		t.Fatalf("expected 2 zones, got %v", obj.Object["zones"])
+	}
+}
+
+func TestPublishOnceUpdatesExistingCR(t *testing.T) {
+	topo := fixtureTopology()
+	cm := &fakeCPUManager{
+		allocatable: cpuset.NewCPUSet(1, 2, 3),
+		allocated:   map[int]cpuset.CPUSet{},
+	}
+	client := newFakeDynamicClient()
+	pub := NewPublisher(client, Config{NodeName: "node-1"}, topo, cm)
+
+	if err := pub.PublishOnce(); err != nil {
+		t.Fatalf("unexpected error on first publish: %v", err)
+	}
+	// A second publish with a different allocation must update, not
+	// duplicate, the CR.
+	cm.allocated = map[int]cpuset.CPUSet{1: cpuset.NewCPUSet(2, 3)}
+	if err := pub.PublishOnce(); err != nil {
+		t.Fatalf("unexpected error on second publish: %v", err)
+	}
+
+	list, err := client.Resource(DefaultGroupVersionResource).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error listing: %v", err)
+	}
+	if len(list.Items) != 1 {
+		t.Fatalf("expected exactly 1 NodeResourceTopology object, got %d", len(list.Items))
+	}
+}
+
+func TestBuildZonesAccountsForReservedAndAllocated(t *testing.T) {
+	topo := fixtureTopology()
+	cm := &fakeCPUManager{
+		allocatable: cpuset.NewCPUSet(1, 2, 3), // CPU 0 reserved
+		allocated:   map[int]cpuset.CPUSet{1: cpuset.NewCPUSet(2)},
+	}
+	pub := NewPublisher(newFakeDynamicClient(), Config{NodeName: "node-1"}, topo, cm)
+
+	zones := pub.buildZones()
+	if len(zones) != 2 {
+		t.Fatalf("expected 2 zones, got %d", len(zones))
+	}
+	for _, z := range zones {
+		switch z.name {
+		case "node-0":
+			if z.capacity != 2 || z.allocatable != 1 || z.available != 1 {
+				t.Errorf("zone node-0: got %+v", z)
+			}
+		case "node-1":
+			if z.capacity != 2 || z.allocatable != 2 || z.available != 1 {
+				t.Errorf("zone node-1: got %+v", z)
+			}
+		default:
+			t.Errorf("unexpected zone %q", z.name)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpumanager
+
+import (
+	v1 "k8s.io/api/core/v1"
+
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpumanager/state"
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
+	"k8s.io/kubernetes/pkg/kubelet/cm/topologymanager"
+)
+
+// PolicyNone name of none policy
+const PolicyNone policyName = "none"
+
+// PolicyStatic name of static policy
+const PolicyStatic policyName = "static"
+
+// CPUPools describes the disjoint pools of CPUs a policy manages, used by
+// external consumers (such as the NodeResourceTopology publisher) that need
+// to report node CPU capacity without reaching into policy internals.
+type CPUPools struct {
+	// Allocatable is the set of CPUs the policy may hand out to pods,
+	// excluding reserved and isolated CPUs.
+	Allocatable cpuset.CPUSet
+	// Reserved is the set of CPUs carved out for system/kube daemons.
+	Reserved cpuset.CPUSet
+	// Isolated is the set of CPUs sourced from the kernel `isolcpus=`
+	// parameter, handed out only via explicit device-plugin request.
+	Isolated cpuset.CPUSet
+}
+
+// Policy implements logic for pod container to CPU assignment.
+type Policy interface {
+	Name() string
+	Start(s state.State) error
+	// AddContainer call is idempotent
+	AddContainer(s state.State, pod *v1.Pod, container *v1.Container) error
+	// RemoveContainer call is idempotent
+	RemoveContainer(s state.State, podUID string, containerName string) error
+	// GetTopologyHints implements the topologymanager.HintProvider Interface
+	// and is consulted to achieve NUMA aware resource alignment among this
+	// and other resource controllers.
+	GetTopologyHints(s state.State, pod v1.Pod, container v1.Container) map[string][]topologymanager.TopologyHint
+	// GetCPUPools returns the policy's current reserved/isolated/allocatable
+	// CPU pools, or the zero value for policies (like none) that do not
+	// partition CPUs.
+	GetCPUPools() CPUPools
+	// IsKubeInfra reports whether pod is classified as platform
+	// infrastructure and therefore pinned to the reserved CPU set rather
+	// than receiving exclusive CPUs. Policies (like none) that do not
+	// partition CPUs always return false.
+	IsKubeInfra(pod *v1.Pod) bool
+}
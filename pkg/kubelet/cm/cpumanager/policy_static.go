@@ -0,0 +1,439 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpumanager
+
+import (
+	"fmt"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog"
+
+	v1qos "k8s.io/kubernetes/pkg/apis/core/v1/helper/qos"
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpumanager/state"
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpumanager/topology"
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
+	"k8s.io/kubernetes/pkg/kubelet/cm/devicemanager"
+	"k8s.io/kubernetes/pkg/kubelet/cm/topologymanager"
+	"k8s.io/kubernetes/pkg/kubelet/cm/topologymanager/bitmask"
+)
+
+// isolCPUsResourceName is the device plugin resource that surfaces
+// kernel-isolated CPUs (`isolcpus=` boot parameter) to the kubelet.
+const isolCPUsResourceName = "windriver.com/isolcpus"
+
+// defaultPlatformLabelKey and defaultPlatformLabelValue identify "platform"
+// pods when the kubelet config does not override them: pods (or their
+// namespace) carrying this label are classified as platform/infrastructure
+// workloads and are pinned to the reserved CPU set instead of receiving
+// exclusive CPUs.
+const (
+	defaultPlatformLabelKey   = "app.starlingx.io/component"
+	defaultPlatformLabelValue = "platform"
+)
+
+// staticPolicy is a CPU manager policy that does not change CPU
+// assignments for running containers until the next container
+// add/remove. This is the default policy for production environments.
+type staticPolicy struct {
+	// topology holds the information about the node's CPU topology.
+	topology *topology.CPUTopology
+
+	// reserved is the set of CPUs reserved for system/kube daemons and
+	// carved out of the topology at policy construction time via
+	// --reserved-cpus or --system/kube-reserved.
+	reserved cpuset.CPUSet
+
+	// isolatedCPUs is the set of CPUs withheld from the normal shared
+	// and exclusive pools, sourced from the kernel `isolcpus=` boot
+	// parameter (surfaced either via --isolcpus or the isolCPUsResourceName
+	// device plugin resource). These CPUs are tracked and accounted for
+	// separately from the exclusive CPU set.
+	isolatedCPUs cpuset.CPUSet
+
+	// devicesManager is consulted to discover which isolated CPUs, if
+	// any, have been allocated to a given container as device plugin
+	// resources.
+	devicesManager devicemanager.Manager
+
+	// affinity provides a hint based on the set of NUMA nodes available.
+	affinity topologymanager.Store
+
+	// namespaceLister is consulted, alongside pod labels, to classify a pod
+	// as "platform" infrastructure. May be nil or not yet synced.
+	namespaceLister corelisters.NamespaceLister
+
+	// platformLabelKey/platformLabelValue identify a "platform" pod: one
+	// whose pod (or namespace) labels carry platformLabelKey=platformLabelValue.
+	// Platform pods are pinned to the reserved CPU set even when they are
+	// Guaranteed QoS with integer CPU requests, so infrastructure workloads
+	// share the reserved pool instead of fragmenting the exclusive pool.
+	platformLabelKey   string
+	platformLabelValue string
+
+	// fullReservedCPUsIsolation, when true (the default), excludes reserved
+	// CPUs from the shared pool handed to Burstable/BestEffort containers,
+	// so user workloads never float onto CPUs dedicated to system/kube
+	// daemons. Set to false via the "full-reserved-cpus-isolation=false"
+	// policy option to restore the legacy behavior of including reserved
+	// CPUs in the shared pool.
+	fullReservedCPUsIsolation bool
+}
+
+// fullReservedCPUsIsolationOption is the policy option used to enable or
+// disable full isolation of reserved CPUs from the shared pool.
+const fullReservedCPUsIsolationOption = "full-reserved-cpus-isolation"
+
+var _ Policy = &staticPolicy{}
+
+// NewStaticPolicy returns a CPU manager policy that does not change CPU
+// assignments for running containers until the next container add/remove.
+func NewStaticPolicy(topo *topology.CPUTopology, numReservedCPUs int, reservedCPUs cpuset.CPUSet, affinity topologymanager.Store, devicesManager devicemanager.Manager, isolatedCPUs cpuset.CPUSet, namespaceLister corelisters.NamespaceLister, platformLabelKey string, platformLabelValue string, policyOptions map[string]string) (Policy, error) {
+	if platformLabelKey == "" {
+		platformLabelKey = defaultPlatformLabelKey
+	}
+	if platformLabelValue == "" {
+		platformLabelValue = defaultPlatformLabelValue
+	}
+
+	fullReservedCPUsIsolation := true
+	if opt, ok := policyOptions[fullReservedCPUsIsolationOption]; ok {
+		parsed, err := strconv.ParseBool(opt)
+		if err != nil {
+			return nil, fmt.Errorf("[cpumanager] invalid value for policy option %q: %v", fullReservedCPUsIsolationOption, err)
+		}
+		fullReservedCPUsIsolation = parsed
+	}
+
+	allCPUs := topo.CPUDetails.CPUs()
+
+	reserved := reservedCPUs
+	if reserved.IsEmpty() {
+		// takeByTopologyNUMAPacked() allocates CPUs from the
+		// topology, but during initialization time, this CPUSet
+		// hasn't been populated yet, so we take reserved CPUs
+		// directly from the topology here.
+		var err error
+		reserved, err = takeByTopologyNUMAPacked(topo, allCPUs, numReservedCPUs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if reserved.Size() != numReservedCPUs {
+		return nil, fmt.Errorf("[cpumanager] unable to reserve the required amount of CPUs (size of %s did not equal %d)", reserved, numReservedCPUs)
+	}
+
+	if !isolatedCPUs.Intersection(reserved).IsEmpty() {
+		return nil, fmt.Errorf("[cpumanager] isolated CPUs %s must not overlap reserved CPUs %s", isolatedCPUs, reserved)
+	}
+
+	klog.Infof("[cpumanager] reserved %d CPUs (\"%s\") not available for exclusive assignment", reserved.Size(), reserved)
+	if !isolatedCPUs.IsEmpty() {
+		klog.Infof("[cpumanager] isolated %d CPUs (\"%s\") available only to integer-CPU Guaranteed pods requesting them explicitly", isolatedCPUs.Size(), isolatedCPUs)
+	}
+
+	return &staticPolicy{
+		topology:                  topo,
+		reserved:                  reserved,
+		isolatedCPUs:              isolatedCPUs,
+		devicesManager:            devicesManager,
+		affinity:                  affinity,
+		namespaceLister:           namespaceLister,
+		platformLabelKey:          platformLabelKey,
+		platformLabelValue:        platformLabelValue,
+		fullReservedCPUsIsolation: fullReservedCPUsIsolation,
+	}, nil
+}
+
+func (p *staticPolicy) Name() string {
+	return string(PolicyStatic)
+}
+
+func (p *staticPolicy) Start(s state.State) error {
+	if err := p.validateState(s); err != nil {
+		klog.Errorf("[cpumanager] static policy invalid state: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (p *staticPolicy) validateState(s state.State) error {
+	tmpAssignments := s.GetCPUAssignments()
+	tmpDefaultCPUset := s.GetDefaultCPUSet()
+
+	if tmpDefaultCPUset.IsEmpty() {
+		s.SetDefaultCPUSet(p.sharedPoolCPUs())
+		return nil
+	}
+
+	for pod := range tmpAssignments {
+		for container, cset := range tmpAssignments[pod] {
+			if cset.IsEmpty() {
+				return fmt.Errorf("empty cpuset assigned to container (pod: %s, container: %s)", pod, container)
+			}
+		}
+	}
+	return nil
+}
+
+// GetCPUPools implements Policy. Allocatable excludes both reserved and
+// isolated CPUs, regardless of the fullReservedCPUsIsolation setting (which
+// only governs what Burstable/BestEffort containers see), since it
+// represents the pool truly available for exclusive pod assignment.
+func (p *staticPolicy) GetCPUPools() CPUPools {
+	allCPUs := p.topology.CPUDetails.CPUs()
+	return CPUPools{
+		Allocatable: allCPUs.Difference(p.reserved).Difference(p.isolatedCPUs),
+		Reserved:    p.reserved,
+		Isolated:    p.isolatedCPUs,
+	}
+}
+
+// sharedPoolCPUs returns the set of CPUs handed to Burstable and BestEffort
+// containers (and used as the starting point for exclusive allocation).
+// Isolated CPUs are always withheld; reserved CPUs are withheld too unless
+// fullReservedCPUsIsolation has been turned off for backward compatibility,
+// in which case Burstable/BestEffort containers may float onto CPUs also
+// used by system/kube-reserved daemons, matching pre-isolation behavior.
+func (p *staticPolicy) sharedPoolCPUs() cpuset.CPUSet {
+	allCPUs := p.topology.CPUDetails.CPUs().Difference(p.isolatedCPUs)
+	if p.fullReservedCPUsIsolation {
+		return allCPUs.Difference(p.reserved)
+	}
+	return allCPUs
+}
+
+// assignableCPUs returns the set of CPUs eligible for exclusive allocation,
+// i.e. everything in the current default (shared) set minus reserved CPUs.
+// Exclusive allocation always excludes reserved CPUs, regardless of the
+// fullReservedCPUsIsolation setting, which only governs the shared pool.
+func (p *staticPolicy) assignableCPUs(s state.State) cpuset.CPUSet {
+	return s.GetDefaultCPUSet().Difference(p.reserved).Difference(p.isolatedCPUs)
+}
+
+// IsKubeInfra implements Policy. It classifies pod as a "platform" pod: one
+// whose own labels, or its namespace's labels, carry
+// platformLabelKey=platformLabelValue. Such pods are pinned to the reserved
+// CPU set regardless of QoS class or CPU request, so control-plane and
+// infrastructure workloads share the reserved pool rather than fragmenting
+// the exclusive pool.
+func (p *staticPolicy) IsKubeInfra(pod *v1.Pod) bool {
+	if value, ok := pod.Labels[p.platformLabelKey]; ok && value == p.platformLabelValue {
+		return true
+	}
+
+	if p.namespaceLister == nil {
+		return false
+	}
+	namespace, err := p.namespaceLister.Get(pod.Namespace)
+	if err != nil {
+		// The namespace lister may not be synced yet (or the namespace may
+		// already be gone); fail safe by treating the pod as a regular
+		// workload rather than platform infrastructure.
+		klog.V(5).Infof("[cpumanager] isKubeInfra: unable to get namespace %q for pod %q: %v", pod.Namespace, pod.Name, err)
+		return false
+	}
+	value, ok := namespace.Labels[p.platformLabelKey]
+	return ok && value == p.platformLabelValue
+}
+
+func (p *staticPolicy) AddContainer(s state.State, pod *v1.Pod, container *v1.Container) error {
+	if _, ok := s.GetCPUSet(string(pod.UID), container.Name); ok {
+		klog.Infof("[cpumanager] static policy: AddContainer for (pod: %s, container: %s) already present in state, skipping", pod.Name, container.Name)
+		return nil
+	}
+
+	// Platform pods are pinned to the reserved CPU set regardless of QoS
+	// class or CPU request shape: Guaranteed platform pods never receive
+	// exclusive (or isolated) CPUs, and Burstable/BestEffort platform pods
+	// never float onto the ordinary shared pool. They share the reserved
+	// pool with other system/kube-reserved daemons instead.
+	if p.IsKubeInfra(pod) {
+		klog.Infof("[cpumanager] static policy: AddContainer (pod: %s, container: %s) is platform infra, pinning to reserved CPUs %s", pod.Name, container.Name, p.reserved)
+		s.SetCPUSet(string(pod.UID), container.Name, p.reserved)
+		return nil
+	}
+
+	if numCPUs := guaranteedCPUs(pod, container); numCPUs != 0 {
+		klog.Infof("[cpumanager] static policy: AddContainer (pod: %s, container: %s)", pod.Name, container.Name)
+
+		// Containers requesting one or more isolated-CPU device plugin
+		// resources are pinned exclusively to those CPUs instead of
+		// going through the normal exclusive allocation path below.
+		// They are tracked separately from the exclusive-CPU set.
+		if isolCPUs := podIsolCPUs(p.devicesManager, pod, container); !isolCPUs.IsEmpty() {
+			klog.Infof("[cpumanager] static policy: AddContainer (pod: %s, container: %s) pinning to isolated CPUs %s", pod.Name, container.Name, isolCPUs)
+			s.SetCPUSet(string(pod.UID), container.Name, isolCPUs)
+			return nil
+		}
+
+		cpuset, err := p.allocateCPUs(s, numCPUs)
+		if err != nil {
+			klog.Errorf("[cpumanager] unable to allocate %d CPUs (pod: %s, container: %s, error: %v)", numCPUs, pod.Name, container.Name, err)
+			return err
+		}
+		s.SetCPUSet(string(pod.UID), container.Name, cpuset)
+	}
+	// container belongs in the shared pool (nothing more to do here).
+	return nil
+}
+
+func (p *staticPolicy) allocateCPUs(s state.State, numCPUs int) (cpuset.CPUSet, error) {
+	klog.Infof("[cpumanager] allocateCpus: (numCPUs: %d)", numCPUs)
+
+	result, err := takeByTopologyNUMAPacked(p.topology, p.assignableCPUs(s), numCPUs)
+	if err != nil {
+		return cpuset.NewCPUSet(), err
+	}
+	s.SetDefaultCPUSet(s.GetDefaultCPUSet().Difference(result))
+
+	klog.Infof("[cpumanager] allocateCPUs: returning \"%v\"", result)
+	return result, nil
+}
+
+func (p *staticPolicy) RemoveContainer(s state.State, podUID string, containerName string) error {
+	klog.Infof("[cpumanager] static policy: RemoveContainer (pod: %s, container: %s)", podUID, containerName)
+	if toRelease, ok := s.GetCPUSet(podUID, containerName); ok {
+		s.Delete(podUID, containerName)
+		// Isolated and reserved CPUs are never taken out of the default set
+		// by an allocation (assignableCPUs excludes both), so only release
+		// CPUs back into the default set when they were exclusively allocated.
+		toRelease = toRelease.Difference(p.isolatedCPUs).Difference(p.reserved)
+		s.SetDefaultCPUSet(s.GetDefaultCPUSet().Union(toRelease))
+	}
+	return nil
+}
+
+func (p *staticPolicy) GetTopologyHints(s state.State, pod v1.Pod, container v1.Container) map[string][]topologymanager.TopologyHint {
+	if _, ok := container.Resources.Requests[v1.ResourceCPU]; !ok {
+		return nil
+	}
+
+	if v1qos.GetPodQOS(&pod) != v1.PodQOSGuaranteed {
+		return nil
+	}
+
+	requested := guaranteedCPUs(&pod, &container)
+	if requested == 0 {
+		return nil
+	}
+
+	// Platform pods are pinned to the reserved CPU set by AddContainer
+	// regardless of their CPU request, so the hints offered here must be
+	// generated from that same set rather than the normal shared/exclusive
+	// pool, or the topology manager could admit the pod on an alignment it
+	// will never actually receive.
+	if p.IsKubeInfra(&pod) {
+		return map[string][]topologymanager.TopologyHint{
+			string(v1.ResourceCPU): p.generateCPUTopologyHints(p.reserved, requested),
+		}
+	}
+
+	// Isolated CPUs are handed out by explicit device-plugin resource
+	// request only, so they are never candidates for the standard
+	// shared/exclusive topology hints.
+	available := p.assignableCPUs(s)
+
+	if allocated, exists := s.GetCPUSet(string(pod.UID), container.Name); exists {
+		if allocated.Size() != requested {
+			klog.Errorf("[cpumanager] GetTopologyHints: CPUs already allocated to (pod %v, container %v) with different number than request: requested: %d, allocated: %d", string(pod.UID), container.Name, requested, allocated.Size())
+			return map[string][]topologymanager.TopologyHint{
+				string(v1.ResourceCPU): {},
+			}
+		}
+		klog.Infof("[cpumanager] GetTopologyHints: regenerating TopologyHints for CPUs already allocated to (pod %v, container %v)", string(pod.UID), container.Name)
+		return map[string][]topologymanager.TopologyHint{
+			string(v1.ResourceCPU): p.generateCPUTopologyHints(allocated, requested),
+		}
+	}
+
+	return map[string][]topologymanager.TopologyHint{
+		string(v1.ResourceCPU): p.generateCPUTopologyHints(available, requested),
+	}
+}
+
+// generateCPUTopologyHints returns one hint for each combination of NUMA
+// nodes whose CPUs in `available` can satisfy `request`, keeping only the
+// combinations with the fewest NUMA nodes; every returned hint is preferred,
+// and larger combinations are not returned. Isolated CPUs have already been
+// excluded from `available`, so they never surface as candidates here.
+func (p *staticPolicy) generateCPUTopologyHints(available cpuset.CPUSet, request int) []topologymanager.TopologyHint {
+	minAffinitySize := p.topology.CPUDetails.NUMANodes().Size()
+	var hints []topologymanager.TopologyHint
+
+	bitmask.IterateBitMasks(p.topology.CPUDetails.NUMANodes().ToSlice(), func(mask bitmask.BitMask) {
+		cpusInMask := p.topology.CPUDetails.CPUsInNUMANodes(mask.GetBits()...).Intersection(available)
+		if cpusInMask.Size() < request {
+			return
+		}
+		if mask.Count() > minAffinitySize {
+			return
+		}
+		if mask.Count() < minAffinitySize {
+			minAffinitySize = mask.Count()
+			hints = nil
+		}
+		hints = append(hints, topologymanager.TopologyHint{
+			NUMANodeAffinity: mask,
+			Preferred:        mask.Count() == minAffinitySize,
+		})
+	})
+	return hints
+}
+
+// guaranteedCPUs returns the number of CPUs that should be exclusively
+// allocated to the container, or zero if the container does not qualify
+// (non-Guaranteed QoS, or a fractional CPU request).
+func guaranteedCPUs(pod *v1.Pod, container *v1.Container) int {
+	if v1qos.GetPodQOS(pod) != v1.PodQOSGuaranteed {
+		return 0
+	}
+	cpuQuantity := container.Resources.Requests[v1.ResourceCPU]
+	if cpuQuantity.Value()*1000 != cpuQuantity.MilliValue() {
+		// Fractional CPU requests never receive exclusive (or isolated) CPUs.
+		return 0
+	}
+	return int(cpuQuantity.Value())
+}
+
+// podIsolCPUs returns the set of isolated CPUs allocated to the given
+// container as an isolCPUsResourceName device plugin resource, or an empty
+// set if none were allocated (or the request is fractional, which never
+// qualifies for isolated CPUs).
+func podIsolCPUs(devicesManager devicemanager.Manager, pod *v1.Pod, container *v1.Container) cpuset.CPUSet {
+	if devicesManager == nil || guaranteedCPUs(pod, container) == 0 {
+		return cpuset.NewCPUSet()
+	}
+
+	cpuIDs := []int{}
+	for _, containerDevices := range devicesManager.GetDevices(string(pod.UID), container.Name) {
+		if containerDevices.ResourceName != isolCPUsResourceName {
+			continue
+		}
+		for _, deviceID := range containerDevices.DeviceIds {
+			id, err := strconv.Atoi(deviceID)
+			if err != nil {
+				klog.Warningf("[cpumanager] podIsolCPUs: non-numeric isolated CPU device ID %q (pod: %s, container: %s)", deviceID, pod.Name, container.Name)
+				continue
+			}
+			cpuIDs = append(cpuIDs, id)
+		}
+	}
+	return cpuset.NewCPUSet(cpuIDs...)
+}
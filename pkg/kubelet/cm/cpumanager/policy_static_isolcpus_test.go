@@ -0,0 +1,176 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpumanager
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	podresourcesapi "k8s.io/kubernetes/pkg/kubelet/apis/podresources/v1alpha1"
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
+	"k8s.io/kubernetes/pkg/kubelet/cm/devicemanager"
+)
+
+// fakeDeviceManager is a devicemanager.Manager that only answers GetDevices,
+// keyed by "podUID/containerName"; the embedded nil Manager satisfies the
+// rest of the (much larger) interface and would panic if podIsolCPUs ever
+// grew to call one of those other methods.
+type fakeDeviceManager struct {
+	devicemanager.Manager
+	devices map[string][]*podresourcesapi.ContainerDevices
+}
+
+func (f *fakeDeviceManager) GetDevices(podUID, containerName string) []*podresourcesapi.ContainerDevices {
+	return f.devices[podUID+"/"+containerName]
+}
+
+func isolGuaranteedPod(cpuRequest string, isolDevices int) *v1.Pod {
+	resources := v1.ResourceRequirements{
+		Requests: v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse(cpuRequest),
+			v1.ResourceMemory: resource.MustParse("1Gi"),
+		},
+	}
+	resources.Limits = resources.Requests.DeepCopy()
+	if isolDevices > 0 {
+		resources.Requests[isolCPUsResourceName] = *resource.NewQuantity(int64(isolDevices), resource.DecimalSI)
+		resources.Limits[isolCPUsResourceName] = resources.Requests[isolCPUsResourceName]
+	}
+	return &v1.Pod{
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:      "test",
+					Resources: resources,
+				},
+			},
+		},
+	}
+}
+
+func TestPodIsolCPUsNoDevicesManager(t *testing.T) {
+	pod := isolGuaranteedPod("2", 2)
+	cset := podIsolCPUs(nil, pod, &pod.Spec.Containers[0])
+	if !cset.IsEmpty() {
+		t.Errorf("expected empty isolated cpuset with nil devicesManager, got %v", cset)
+	}
+}
+
+func TestPodIsolCPUsFractionalNeverIsolated(t *testing.T) {
+	pod := isolGuaranteedPod("500m", 1)
+	pod.UID = "isol-pod-uid"
+	dm := &fakeDeviceManager{
+		devices: map[string][]*podresourcesapi.ContainerDevices{
+			"isol-pod-uid/test": {
+				{ResourceName: isolCPUsResourceName, DeviceIds: []string{"5"}},
+			},
+		},
+	}
+	cset := podIsolCPUs(dm, pod, &pod.Spec.Containers[0])
+	if !cset.IsEmpty() {
+		t.Errorf("expected empty isolated cpuset for fractional CPU request, got %v", cset)
+	}
+}
+
+func TestGuaranteedCPUsSkipsFractional(t *testing.T) {
+	pod := isolGuaranteedPod("1500m", 0)
+	if n := guaranteedCPUs(pod, &pod.Spec.Containers[0]); n != 0 {
+		t.Errorf("expected 0 guaranteed CPUs for fractional request, got %d", n)
+	}
+}
+
+func TestGuaranteedCPUsInteger(t *testing.T) {
+	pod := isolGuaranteedPod("2", 0)
+	if n := guaranteedCPUs(pod, &pod.Spec.Containers[0]); n != 2 {
+		t.Errorf("expected 2 guaranteed CPUs for integer request, got %d", n)
+	}
+}
+
+// TestPodIsolCPUsFromDeviceManager exercises the branch of podIsolCPUs that
+// actually consults the device manager, verifying that the isolCPUsResourceName
+// device IDs it reports are parsed into the returned cpuset.
+func TestPodIsolCPUsFromDeviceManager(t *testing.T) {
+	pod := isolGuaranteedPod("2", 2)
+	pod.UID = "isol-pod-uid"
+	dm := &fakeDeviceManager{
+		devices: map[string][]*podresourcesapi.ContainerDevices{
+			"isol-pod-uid/test": {
+				{ResourceName: isolCPUsResourceName, DeviceIds: []string{"5", "6"}},
+			},
+		},
+	}
+
+	cset := podIsolCPUs(dm, pod, &pod.Spec.Containers[0])
+	want := cpuset.NewCPUSet(5, 6)
+	if !cset.Equals(want) {
+		t.Errorf("expected isolated cpuset %v, got %v", want, cset)
+	}
+}
+
+// TestPodIsolCPUsFromDeviceManagerIgnoresOtherResources verifies that device
+// plugin resources other than isolCPUsResourceName are skipped.
+func TestPodIsolCPUsFromDeviceManagerIgnoresOtherResources(t *testing.T) {
+	pod := isolGuaranteedPod("2", 2)
+	pod.UID = "isol-pod-uid"
+	dm := &fakeDeviceManager{
+		devices: map[string][]*podresourcesapi.ContainerDevices{
+			"isol-pod-uid/test": {
+				{ResourceName: "example.com/other-device", DeviceIds: []string{"9"}},
+			},
+		},
+	}
+
+	cset := podIsolCPUs(dm, pod, &pod.Spec.Containers[0])
+	if !cset.IsEmpty() {
+		t.Errorf("expected no isolated CPUs from an unrelated device resource, got %v", cset)
+	}
+}
+
+// TestAddContainerPinsIsolCPUsFromDeviceManager exercises
+// staticPolicy.AddContainer end to end for a pod that actually requests the
+// isolCPUsResourceName device resource, verifying it is pinned to the CPUs
+// the device manager reports rather than going through the normal exclusive
+// allocation path.
+func TestAddContainerPinsIsolCPUsFromDeviceManager(t *testing.T) {
+	pod := isolGuaranteedPod("2", 2)
+	pod.UID = "isol-pod-uid"
+	container := pod.Spec.Containers[0]
+	dm := &fakeDeviceManager{
+		devices: map[string][]*podresourcesapi.ContainerDevices{
+			"isol-pod-uid/test": {
+				{ResourceName: isolCPUsResourceName, DeviceIds: []string{"5", "6"}},
+			},
+		},
+	}
+	p := &staticPolicy{devicesManager: dm}
+	s := &recordingState{}
+
+	if err := p.AddContainer(s, pod, &container); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cset, ok := s.GetCPUSet(string(pod.UID), container.Name)
+	if !ok {
+		t.Fatalf("expected a cpuset to be recorded for the container")
+	}
+	want := cpuset.NewCPUSet(5, 6)
+	if !cset.Equals(want) {
+		t.Errorf("expected isolated CPUs %v pinned, got %v", want, cset)
+	}
+}
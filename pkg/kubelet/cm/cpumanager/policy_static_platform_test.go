@@ -0,0 +1,237 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpumanager
+
+import (
+	"fmt"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpumanager/state"
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
+)
+
+// recordingState is a minimal state.State that records the last cpuset
+// written via SetCPUSet, sufficient to assert on staticPolicy.AddContainer
+// without needing a real checkpointed state implementation.
+type recordingState struct {
+	defaultCPUs cpuset.CPUSet
+	lastPodUID  string
+	lastName    string
+	lastCPUs    cpuset.CPUSet
+	set         bool
+}
+
+func (r *recordingState) GetCPUSet(podUID, containerName string) (cpuset.CPUSet, bool) {
+	if r.set && podUID == r.lastPodUID && containerName == r.lastName {
+		return r.lastCPUs, true
+	}
+	return cpuset.CPUSet{}, false
+}
+func (r *recordingState) GetDefaultCPUSet() cpuset.CPUSet { return r.defaultCPUs }
+func (r *recordingState) GetCPUSetOrDefault(podUID, containerName string) cpuset.CPUSet {
+	if cset, ok := r.GetCPUSet(podUID, containerName); ok {
+		return cset
+	}
+	return r.defaultCPUs
+}
+func (r *recordingState) GetCPUAssignments() state.ContainerCPUAssignments {
+	return state.ContainerCPUAssignments{}
+}
+func (r *recordingState) SetCPUSet(podUID, containerName string, cset cpuset.CPUSet) {
+	r.lastPodUID, r.lastName, r.lastCPUs, r.set = podUID, containerName, cset, true
+}
+func (r *recordingState) SetDefaultCPUSet(cset cpuset.CPUSet)               { r.defaultCPUs = cset }
+func (r *recordingState) SetCPUAssignments(a state.ContainerCPUAssignments) {}
+func (r *recordingState) Delete(podUID, containerName string)               { r.set = false }
+func (r *recordingState) ClearState()                                       { r.set = false }
+
+type fakeNamespaceLister struct {
+	namespaces map[string]*v1.Namespace
+}
+
+func (f *fakeNamespaceLister) List(selector labels.Selector) ([]*v1.Namespace, error) {
+	var result []*v1.Namespace
+	for _, ns := range f.namespaces {
+		result = append(result, ns)
+	}
+	return result, nil
+}
+
+func (f *fakeNamespaceLister) Get(name string) (*v1.Namespace, error) {
+	ns, ok := f.namespaces[name]
+	if !ok {
+		return nil, fmt.Errorf("namespace %q not found", name)
+	}
+	return ns, nil
+}
+
+func platformTestPod(namespace string, podLabels map[string]string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Labels:    podLabels,
+		},
+	}
+}
+
+func TestIsKubeInfra(t *testing.T) {
+	platformNS := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "platform-ns",
+			Labels: map[string]string{defaultPlatformLabelKey: defaultPlatformLabelValue},
+		},
+	}
+	plainNS := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "plain-ns"},
+	}
+	lister := &fakeNamespaceLister{namespaces: map[string]*v1.Namespace{
+		"platform-ns": platformNS,
+		"plain-ns":    plainNS,
+	}}
+
+	testCases := []struct {
+		description string
+		pod         *v1.Pod
+		expected    bool
+	}{
+		{
+			description: "platform label on pod only",
+			pod:         platformTestPod("plain-ns", map[string]string{defaultPlatformLabelKey: defaultPlatformLabelValue}),
+			expected:    true,
+		},
+		{
+			description: "platform label on namespace only",
+			pod:         platformTestPod("platform-ns", nil),
+			expected:    true,
+		},
+		{
+			description: "neither pod nor namespace labeled",
+			pod:         platformTestPod("plain-ns", nil),
+			expected:    false,
+		},
+		{
+			description: "both pod and namespace labeled",
+			pod:         platformTestPod("platform-ns", map[string]string{defaultPlatformLabelKey: defaultPlatformLabelValue}),
+			expected:    true,
+		},
+	}
+
+	p := &staticPolicy{
+		namespaceLister:    lister,
+		platformLabelKey:   defaultPlatformLabelKey,
+		platformLabelValue: defaultPlatformLabelValue,
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			if got := p.IsKubeInfra(tc.pod); got != tc.expected {
+				t.Errorf("IsKubeInfra() = %v, expected %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestIsKubeInfraNilLister(t *testing.T) {
+	p := &staticPolicy{
+		platformLabelKey:   defaultPlatformLabelKey,
+		platformLabelValue: defaultPlatformLabelValue,
+	}
+	pod := platformTestPod("unsynced-ns", nil)
+	if p.IsKubeInfra(pod) {
+		t.Errorf("expected IsKubeInfra to fail safe to false with nil namespace lister")
+	}
+}
+
+// TestAddContainerPinsBurstablePlatformPodToReserved verifies that a
+// platform-labeled pod is routed to the reserved CPU set even when it is
+// Burstable (no CPU limits set), not just when it is Guaranteed with an
+// integer CPU request. Otherwise, after the shared pool excludes reserved
+// CPUs, such a pod would land on the ordinary shared pool like any other
+// workload instead of being colocated with the reserved pool it is meant
+// to share with other platform infra.
+func TestAddContainerPinsBurstablePlatformPodToReserved(t *testing.T) {
+	reserved := cpuset.NewCPUSet(0)
+	p := &staticPolicy{
+		reserved:           reserved,
+		platformLabelKey:   defaultPlatformLabelKey,
+		platformLabelValue: defaultPlatformLabelValue,
+	}
+	pod := platformTestPod("plain-ns", map[string]string{defaultPlatformLabelKey: defaultPlatformLabelValue})
+	pod.UID = "platform-pod-uid"
+	container := v1.Container{Name: "test"}
+	pod.Spec.Containers = []v1.Container{container}
+
+	s := &recordingState{}
+	if err := p.AddContainer(s, pod, &container); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cset, ok := s.GetCPUSet(string(pod.UID), container.Name)
+	if !ok {
+		t.Fatalf("expected a cpuset to be recorded for platform pod")
+	}
+	if !cset.Equals(reserved) {
+		t.Errorf("expected platform pod pinned to reserved CPUs %v, got %v", reserved, cset)
+	}
+}
+
+// TestGetTopologyHintsForPlatformPod verifies that a Guaranteed platform
+// pod's hints are generated from the reserved CPU set, matching what
+// AddContainer will actually pin it to, rather than from the normal
+// shared/exclusive pool.
+func TestGetTopologyHintsForPlatformPod(t *testing.T) {
+	topo := twoSocketTopology()
+	reserved := cpuset.NewCPUSet(0, 2) // spans both NUMA nodes
+
+	p := &staticPolicy{
+		topology:           topo,
+		reserved:           reserved,
+		isolatedCPUs:       cpuset.NewCPUSet(),
+		platformLabelKey:   defaultPlatformLabelKey,
+		platformLabelValue: defaultPlatformLabelValue,
+	}
+
+	pod := *platformTestPod("plain-ns", map[string]string{defaultPlatformLabelKey: defaultPlatformLabelValue})
+	container := v1.Container{
+		Name: "test",
+		Resources: v1.ResourceRequirements{
+			Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1"), v1.ResourceMemory: resource.MustParse("1Gi")},
+			Limits:   v1.ResourceList{v1.ResourceCPU: resource.MustParse("1"), v1.ResourceMemory: resource.MustParse("1Gi")},
+		},
+	}
+	pod.Spec.Containers = []v1.Container{container}
+
+	hints := p.GetTopologyHints(&recordingState{}, pod, container)
+	cpuHints, ok := hints[string(v1.ResourceCPU)]
+	if !ok {
+		t.Fatalf("expected a CPU hints entry for platform pod, got %v", hints)
+	}
+
+	want := p.generateCPUTopologyHints(reserved, 1)
+	if len(cpuHints) != len(want) {
+		t.Fatalf("expected hints generated from reserved CPUs %v (%v), got %v", reserved, want, cpuHints)
+	}
+	for i := range want {
+		if !cpuHints[i].NUMANodeAffinity.IsEqual(want[i].NUMANodeAffinity) || cpuHints[i].Preferred != want[i].Preferred {
+			t.Errorf("expected hint %v generated from reserved CPUs, got %v", want[i], cpuHints[i])
+		}
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpumanager
+
+import (
+	"testing"
+
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpumanager/topology"
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
+)
+
+// twoSocketTopology returns a 4 CPU topology spanning two sockets/NUMA
+// nodes (CPUs 0-1 on socket/NUMA 0, CPUs 2-3 on socket/NUMA 1). Tests set
+// any reserved CPUs on the policy themselves.
+func twoSocketTopology() *topology.CPUTopology {
+	return &topology.CPUTopology{
+		NumCPUs:    4,
+		NumCores:   4,
+		NumSockets: 2,
+		CPUDetails: topology.CPUDetails{
+			0: topology.CPUInfo{CoreID: 0, SocketID: 0, NUMANodeID: 0},
+			1: topology.CPUInfo{CoreID: 1, SocketID: 0, NUMANodeID: 0},
+			2: topology.CPUInfo{CoreID: 2, SocketID: 1, NUMANodeID: 1},
+			3: topology.CPUInfo{CoreID: 3, SocketID: 1, NUMANodeID: 1},
+		},
+	}
+}
+
+func TestSharedPoolExcludesReservedBySocket(t *testing.T) {
+	topo := twoSocketTopology()
+	reserved := cpuset.NewCPUSet(0, 2) // one reserved CPU per socket
+
+	p := &staticPolicy{
+		topology:                  topo,
+		reserved:                  reserved,
+		isolatedCPUs:              cpuset.NewCPUSet(),
+		fullReservedCPUsIsolation: true,
+	}
+
+	shared := p.sharedPoolCPUs()
+	if shared.Size() != 2 {
+		t.Fatalf("expected 2 shared CPUs with reserved fully isolated, got %v", shared)
+	}
+	if shared.Intersection(reserved).Size() != 0 {
+		t.Errorf("expected shared pool %v to exclude reserved CPUs %v", shared, reserved)
+	}
+}
+
+func TestSharedPoolIncludesReservedWhenIsolationDisabled(t *testing.T) {
+	topo := twoSocketTopology()
+	reserved := cpuset.NewCPUSet(0, 2)
+
+	p := &staticPolicy{
+		topology:                  topo,
+		reserved:                  reserved,
+		isolatedCPUs:              cpuset.NewCPUSet(),
+		fullReservedCPUsIsolation: false,
+	}
+
+	shared := p.sharedPoolCPUs()
+	if shared.Size() != 4 {
+		t.Fatalf("expected all 4 CPUs in shared pool with isolation disabled, got %v", shared)
+	}
+}